@@ -0,0 +1,99 @@
+package modd
+
+import (
+	"os"
+	"path"
+
+	"github.com/rjeczalik/notify"
+)
+
+// Backend is a pluggable source of filesystem events. WatchConfig.Watch
+// drives a Backend instead of talking to a specific notification mechanism
+// directly, so that platforms or environments where the OS-native
+// mechanism is unreliable - network mounts, containers, WSL, or platforms
+// notify has no native support for - can fall back to a different
+// implementation and still produce Mod values through the same channel.
+type Backend interface {
+	// Register starts watching p, recursing into subdirectories if
+	// recursive is true and p is a directory.
+	Register(p string, recursive bool) error
+	// Events returns the channel events are delivered on.
+	Events() chan notify.EventInfo
+	// Overflowed returns a channel that's signalled whenever the backend
+	// had to drop events because Events() wasn't being drained fast
+	// enough. WatchConfig.Watch responds by resynchronizing from a fresh
+	// walk of the watched paths.
+	Overflowed() <-chan struct{}
+	// Close stops watching and releases the backend's resources.
+	Close() error
+}
+
+// notifyBackend is the default Backend, backed by rjeczalik/notify - the
+// platform's native filesystem change notification mechanism (inotify,
+// kqueue, FSEvents, ReadDirectoryChangesW).
+//
+// notify.Watch is given an internal channel that a pump goroutine drains
+// as fast as it can, forwarding onto the channel Events() exposes. If a
+// slow consumer lets that outer channel fill up, the pump drops the event
+// rather than blocking - which would otherwise risk stalling notify's own
+// dispatch goroutine - and signals Overflowed() instead.
+type notifyBackend struct {
+	raw      chan notify.EventInfo
+	out      chan notify.EventInfo
+	overflow chan struct{}
+	done     chan struct{}
+}
+
+func newNotifyBackend() *notifyBackend {
+	b := &notifyBackend{
+		raw:      make(chan notify.EventInfo, 1024),
+		out:      make(chan notify.EventInfo, 1024),
+		overflow: make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	go b.pump()
+	return b
+}
+
+func (b *notifyBackend) pump() {
+	for {
+		select {
+		case evt := <-b.raw:
+			select {
+			case b.out <- evt:
+			default:
+				select {
+				case b.overflow <- struct{}{}:
+				default:
+				}
+			}
+		case <-b.done:
+			return
+		}
+	}
+}
+
+func (b *notifyBackend) Register(p string, recursive bool) error {
+	stat, err := os.Stat(p)
+	if err != nil {
+		return err
+	}
+	if stat.IsDir() && recursive {
+		p = path.Join(p, "...")
+	}
+	return notify.Watch(p, b.raw, notify.All)
+}
+
+func (b *notifyBackend) Events() chan notify.EventInfo {
+	return b.out
+}
+
+func (b *notifyBackend) Overflowed() <-chan struct{} {
+	return b.overflow
+}
+
+func (b *notifyBackend) Close() error {
+	notify.Stop(b.raw)
+	close(b.done)
+	return nil
+}
@@ -0,0 +1,265 @@
+package modd
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ChangeKind describes the nature of a single file change within a Mod.
+type ChangeKind int
+
+// The kinds of change a Change can represent. There's no Chmod: nothing in
+// this package - not notify's cross-platform event set, not the poll
+// backend's size/mtime diff - actually detects a permission-only change, so
+// there's no ChangeKind for one until something does.
+const (
+	Modify ChangeKind = iota
+	Add
+	Delete
+	Rename
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Modify:
+		return "Modify"
+	case Add:
+		return "Add"
+	case Delete:
+		return "Delete"
+	case Rename:
+		return "Rename"
+	default:
+		return "Unknown"
+	}
+}
+
+// Change describes a single file change. OldPath is only set on Rename
+// changes, where it holds the file's previous path. Hash is only set when
+// WatchConfig.ContentHash is enabled, and is empty for directories and for
+// files skipped because of ContentHashSizeCap.
+type Change struct {
+	Path    string
+	Kind    ChangeKind
+	OldPath string
+	IsDir   bool
+	Size    int64
+	ModTime time.Time
+	Hash    string
+}
+
+// statInfo is a snapshot of a path's stat data.
+type statInfo struct {
+	info os.FileInfo
+	size int64
+	mod  time.Time
+}
+
+func statAt(p string) (statInfo, bool) {
+	fi, err := os.Lstat(p)
+	if err != nil {
+		return statInfo{}, false
+	}
+	return statInfo{info: fi, size: fi.Size(), mod: fi.ModTime()}, true
+}
+
+func (si statInfo) isDir() bool {
+	return si.info != nil && si.info.IsDir()
+}
+
+// sameFile decides whether two stat snapshots describe what's probably the
+// same underlying file - used to pair up the add and remove halves of a
+// rename. Either a match on the OS's notion of file identity (inode on
+// Unix, file index on Windows) or an equal size and mtime counts as the
+// same file, so a size/mtime match is accepted even when the OS reports
+// two different files.
+func sameFile(a, b statInfo) bool {
+	if os.SameFile(a.info, b.info) {
+		return true
+	}
+	return a.size == b.size && a.mod.Equal(b.mod)
+}
+
+// renameCache remembers the last known stat of every path we've seen, so
+// that when a path disappears - as it always has, by the time its Remove or
+// Rename event reaches us - we can still compare it against a newly added
+// path to tell whether the two are really one file that moved. It's seeded
+// with a walk of the watched roots at startup and kept up to date as events
+// arrive, and lives for the lifetime of a single WatchConfig.Watch call.
+// Since the overflow-triggered resync runs in its own goroutine alongside
+// the regular batch loop, access is guarded by a mutex.
+type renameCache struct {
+	mu    sync.Mutex
+	stats map[string]statInfo
+}
+
+func newRenameCache() *renameCache {
+	return &renameCache{stats: make(map[string]statInfo)}
+}
+
+// seed walks root, recording a stat snapshot for everything found. If
+// recursive is false, only root itself and its direct contents are
+// recorded, matching the scope the backend was actually registered to
+// watch. Errors are ignored - we're only building a best-effort cache.
+func (rc *renameCache) seed(root string, recursive bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	walkSpec(root, recursive, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		rc.stats[p] = statInfo{info: info, size: info.Size(), mod: info.ModTime()}
+		return nil
+	})
+}
+
+// walkSpec invokes fn for root and, if root is a directory, for its
+// contents - recursing into subdirectories only when recursive is true, so
+// that a non-recursive WatchSpec never produces stat data for paths the
+// backend was never asked to watch.
+func walkSpec(root string, recursive bool, fn filepath.WalkFunc) error {
+	if recursive {
+		return filepath.Walk(root, fn)
+	}
+	info, err := os.Lstat(root)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+	if err := fn(root, info, nil); err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return nil
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if err := fn(filepath.Join(root, e.Name()), info, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// observe refreshes the cached stat for p if it still exists.
+func (rc *renameCache) observe(p string) {
+	si, ok := statAt(p)
+	if !ok {
+		return
+	}
+	rc.mu.Lock()
+	rc.stats[p] = si
+	rc.mu.Unlock()
+}
+
+// lookup returns the last known stat recorded for p, which may well be
+// stale - it's normally called for paths that no longer exist.
+func (rc *renameCache) lookup(p string) (statInfo, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	si, ok := rc.stats[p]
+	return si, ok
+}
+
+// rename moves the cached entry for from to to, as used when from and to
+// have been identified as the two halves of a rename.
+func (rc *renameCache) rename(from, to string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if si, ok := rc.stats[from]; ok {
+		rc.stats[to] = si
+	}
+	delete(rc.stats, from)
+}
+
+// forget drops p from the cache, once we're sure it's really gone.
+func (rc *renameCache) forget(p string) {
+	rc.mu.Lock()
+	delete(rc.stats, p)
+	rc.mu.Unlock()
+}
+
+// resync walks specs - respecting each spec's Recursive flag, so it never
+// reports changes for paths the backend was never watching - diffing the
+// result against the cache's last known state, and returns the Changes
+// needed to bring observers back up to date. It's used to recover after the
+// backend reports a lost or overflowed event stream, where some changes may
+// otherwise go unnoticed.
+func (rc *renameCache) resync(specs []WatchSpec) []Change {
+	cur := make(map[string]statInfo)
+	for _, spec := range specs {
+		walkSpec(spec.Path, spec.Recursive, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			cur[p] = statInfo{info: info, size: info.Size(), mod: info.ModTime()}
+			return nil
+		})
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	changeMap := make(map[string]Change)
+	for p, si := range cur {
+		old, ok := rc.stats[p]
+		switch {
+		case !ok:
+			changeMap[p] = Change{Path: p, Kind: Add, IsDir: si.isDir(), Size: si.size, ModTime: si.mod}
+		case old.size != si.size || !old.mod.Equal(si.mod):
+			changeMap[p] = Change{Path: p, Kind: Modify, IsDir: si.isDir(), Size: si.size, ModTime: si.mod}
+		}
+	}
+	for p := range rc.stats {
+		if _, ok := cur[p]; !ok {
+			changeMap[p] = Change{Path: p, Kind: Delete}
+		}
+	}
+	rc.stats = cur
+
+	keys := make(map[string]bool, len(changeMap))
+	for k := range changeMap {
+		keys[k] = true
+	}
+	changes := make([]Change, 0, len(changeMap))
+	for _, k := range _keys(keys) {
+		changes = append(changes, changeMap[k])
+	}
+	return changes
+}
+
+// changesToMod builds the legacy Added/Changed/Deleted views from a list of
+// Changes, preserved for backward compatibility.
+func changesToMod(changes []Change) *Mod {
+	added := make(map[string]bool)
+	changed := make(map[string]bool)
+	deleted := make(map[string]bool)
+	for _, c := range changes {
+		switch c.Kind {
+		case Add:
+			added[c.Path] = true
+		case Rename:
+			added[c.Path] = true
+			if c.OldPath != "" {
+				deleted[c.OldPath] = true
+			}
+		case Delete:
+			deleted[c.Path] = true
+		case Modify:
+			changed[c.Path] = true
+		}
+	}
+	return &Mod{
+		Changes: changes,
+		Added:   _keys(added),
+		Changed: _keys(changed),
+		Deleted: _keys(deleted),
+	}
+}
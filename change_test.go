@@ -0,0 +1,142 @@
+package modd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rjeczalik/notify"
+)
+
+func TestSameFile(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name string
+		a, b statInfo
+		want bool
+	}{
+		{"identical size and mtime", statInfo{size: 10, mod: now}, statInfo{size: 10, mod: now}, true},
+		{"different size", statInfo{size: 10, mod: now}, statInfo{size: 11, mod: now}, false},
+		{"different mtime", statInfo{size: 10, mod: now}, statInfo{size: 10, mod: now.Add(time.Second)}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := sameFile(c.a, c.b); got != c.want {
+				t.Errorf("sameFile(%+v, %+v) = %v, want %v", c.a, c.b, got, c.want)
+			}
+		})
+	}
+}
+
+// fakeExistence reports existence from a fixed set of paths, standing in
+// for statExistenceChecker so tests don't need real files on disk.
+type fakeExistence map[string]bool
+
+func (f fakeExistence) Check(p string) bool { return f[p] }
+
+func TestBatchRenamePairing(t *testing.T) {
+	root := t.TempDir()
+	oldPath := filepath.Join(root, "old.txt")
+	newPath := filepath.Join(root, "new.txt")
+	si := statInfo{size: 42, mod: time.Unix(1700000000, 0)}
+
+	renames := newRenameCache()
+	renames.stats[oldPath] = si
+	renames.stats[newPath] = si
+
+	exists := fakeExistence{newPath: true}
+
+	ch := make(chan notify.EventInfo, 4)
+	ch <- pollEvent{path: oldPath, event: notify.Rename}
+	ch <- pollEvent{path: newPath, event: notify.Rename}
+
+	mod := batch(10*time.Millisecond, exists, ch, renames, nil)
+
+	if len(mod.Changes) != 1 {
+		t.Fatalf("expected a single Change, got %d: %+v", len(mod.Changes), mod.Changes)
+	}
+	c := mod.Changes[0]
+	if c.Kind != Rename {
+		t.Fatalf("expected Kind Rename, got %v", c.Kind)
+	}
+	if c.Path != newPath || c.OldPath != oldPath {
+		t.Fatalf("expected rename %s -> %s, got OldPath=%s Path=%s", oldPath, newPath, c.OldPath, c.Path)
+	}
+
+	// Renaming should carry the cached stat forward under the new path and
+	// drop the old one.
+	if _, ok := renames.lookup(oldPath); ok {
+		t.Errorf("expected renameCache to forget %s after pairing", oldPath)
+	}
+	if _, ok := renames.lookup(newPath); !ok {
+		t.Errorf("expected renameCache to retain an entry for %s after pairing", newPath)
+	}
+}
+
+func TestBatchRenameWithoutCacheFallsBackToAddDelete(t *testing.T) {
+	root := t.TempDir()
+	oldPath := filepath.Join(root, "old.txt")
+	newPath := filepath.Join(root, "new.txt")
+
+	// No renameCache data for either path - sameFile has nothing to compare,
+	// so this must surface as a plain Add and Delete rather than a Rename.
+	renames := newRenameCache()
+	exists := fakeExistence{newPath: true}
+
+	ch := make(chan notify.EventInfo, 4)
+	ch <- pollEvent{path: oldPath, event: notify.Rename}
+	ch <- pollEvent{path: newPath, event: notify.Rename}
+
+	mod := batch(10*time.Millisecond, exists, ch, renames, nil)
+
+	kinds := make(map[string]ChangeKind)
+	for _, c := range mod.Changes {
+		kinds[c.Path] = c.Kind
+	}
+	if kinds[newPath] != Add {
+		t.Errorf("expected %s to be Add, got %v", newPath, kinds[newPath])
+	}
+	if kinds[oldPath] != Delete {
+		t.Errorf("expected %s to be Delete, got %v", oldPath, kinds[oldPath])
+	}
+}
+
+func TestWalkSpecNonRecursiveStopsAtOneLevel(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "sub", "deeper"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "top.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "sub", "nested.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var seen []string
+	err := walkSpec(root, false, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		seen = append(seen, p)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{
+		root:                           true,
+		filepath.Join(root, "top.txt"): true,
+		filepath.Join(root, "sub"):     true,
+	}
+	if len(seen) != len(want) {
+		t.Fatalf("walkSpec(recursive=false) visited %v, want exactly %v", seen, want)
+	}
+	for _, p := range seen {
+		if !want[p] {
+			t.Errorf("walkSpec(recursive=false) unexpectedly visited %s", p)
+		}
+	}
+}
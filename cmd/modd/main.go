@@ -18,11 +18,9 @@ func main() {
 	kingpin.Parse()
 
 	modchan := make(chan modd.Mod)
-	for _, path := range *paths {
-		err := modd.Watch(path, batchTime, modchan)
-		if err != nil {
-			kingpin.Fatalf("Fatal error: %s", err)
-		}
+	err := modd.Watch(*paths, nil, batchTime, modchan)
+	if err != nil {
+		kingpin.Fatalf("Fatal error: %s", err)
 	}
 	for mod := range modchan {
 		if len(mod.Added) > 0 {
@@ -0,0 +1,129 @@
+package modd
+
+import (
+	"container/list"
+	"encoding/hex"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// defaultContentHashSizeCap is used when WatchConfig.ContentHash is set but
+// ContentHashSizeCap is zero - files larger than this are never hashed.
+const defaultContentHashSizeCap = 8 * 1024 * 1024
+
+// contentCacheSize bounds how many file hashes we remember at once. This is
+// meant to catch back-to-back no-op saves of actively edited files, not to
+// fingerprint an entire tree, so it's kept small and isn't configurable.
+const contentCacheSize = 4096
+
+// contentCache is a small LRU of path -> last-seen content hash, used to
+// drop Modify changes whose content hasn't actually changed. Editors that
+// save-with-no-change, atomic-rename saves, and tools that only touch
+// mtimes all generate spurious Write events that this collapses. We use
+// FNV-1a rather than a cryptographic hash since this is a fingerprint for
+// debouncing, not a security boundary.
+type contentCache struct {
+	mu      sync.Mutex
+	sizeCap int64
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type contentEntry struct {
+	path string
+	hash string
+}
+
+func newContentCache(sizeCap int64) *contentCache {
+	return &contentCache{
+		sizeCap: sizeCap,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// seed walks root, recording an initial hash for every file found, so that
+// the first real event seen for an existing file has something to compare
+// against rather than being reported as changed by default.
+func (c *contentCache) seed(root string) {
+	filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		if hash, ok := c.hashFile(p); ok {
+			c.record(p, hash)
+		}
+		return nil
+	})
+}
+
+// hashFile computes a content fingerprint for p, or reports false if p is
+// too large (per sizeCap) or can't be read.
+func (c *contentCache) hashFile(p string) (string, bool) {
+	if c.sizeCap > 0 {
+		fi, err := os.Stat(p)
+		if err != nil || fi.IsDir() || fi.Size() > c.sizeCap {
+			return "", false
+		}
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+	h := fnv.New64a()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", false
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// record stores hash as p's last-seen content hash, reporting whether it
+// matches what was previously recorded for p.
+func (c *contentCache) record(p, hash string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[p]; ok {
+		entry := el.Value.(*contentEntry)
+		same := entry.hash == hash
+		entry.hash = hash
+		c.order.MoveToFront(el)
+		return same
+	}
+	el := c.order.PushFront(&contentEntry{path: p, hash: hash})
+	c.entries[p] = el
+	for c.order.Len() > contentCacheSize {
+		oldest := c.order.Back()
+		if oldest == nil {
+			break
+		}
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*contentEntry).path)
+	}
+	return false
+}
+
+// Unchanged hashes p and reports the hash along with whether it matches
+// what was last recorded for p - the cache is updated either way. ok is
+// false if p couldn't be hashed, in which case hash and unchanged are both
+// zero values.
+func (c *contentCache) Unchanged(p string) (hash string, unchanged bool, ok bool) {
+	hash, ok = c.hashFile(p)
+	if !ok {
+		return "", false, false
+	}
+	return hash, c.record(p, hash), true
+}
+
+// hashIfEnabled is a nil-safe wrapper around contentCache.Unchanged: with no
+// cache configured, every path is reported as hash-less and changed.
+func hashIfEnabled(c *contentCache, p string) (hash string, unchanged bool) {
+	if c == nil {
+		return "", false
+	}
+	hash, unchanged, _ = c.Unchanged(p)
+	return hash, unchanged
+}
@@ -0,0 +1,91 @@
+package modd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestContentCacheRecordDetectsUnchanged(t *testing.T) {
+	c := newContentCache(0)
+	if same := c.record("a", "hash1"); same {
+		t.Error("first record of a path should never report unchanged")
+	}
+	if same := c.record("a", "hash1"); !same {
+		t.Error("recording the same hash again should report unchanged")
+	}
+	if same := c.record("a", "hash2"); same {
+		t.Error("recording a different hash should report changed")
+	}
+}
+
+func TestContentCacheEvictsOldest(t *testing.T) {
+	c := newContentCache(0)
+	for i := 0; i < contentCacheSize+10; i++ {
+		c.record(fmt.Sprintf("path%d", i), "h")
+	}
+	if len(c.entries) != contentCacheSize {
+		t.Fatalf("expected cache to be capped at %d entries, got %d", contentCacheSize, len(c.entries))
+	}
+	if _, ok := c.entries["path0"]; ok {
+		t.Error("expected the oldest entry to have been evicted")
+	}
+}
+
+func TestContentCacheHashFileRespectsSizeCap(t *testing.T) {
+	dir := t.TempDir()
+	small := filepath.Join(dir, "small.txt")
+	big := filepath.Join(dir, "big.txt")
+	if err := os.WriteFile(small, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(big, []byte("xxxxxxxxxx"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newContentCache(5)
+	if _, ok := c.hashFile(small); !ok {
+		t.Error("expected small file under the size cap to hash successfully")
+	}
+	if _, ok := c.hashFile(big); ok {
+		t.Error("expected file over the size cap to be skipped")
+	}
+}
+
+func TestContentCacheUnchangedAfterSeed(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "f.txt")
+	if err := os.WriteFile(p, []byte("same content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newContentCache(0)
+	c.seed(dir)
+
+	// A no-op mtime touch - content unchanged - should be reported as
+	// unchanged on the first real observation after seeding.
+	touched := time.Now().Add(time.Minute)
+	if err := os.Chtimes(p, touched, touched); err != nil {
+		t.Fatal(err)
+	}
+	_, unchanged, ok := c.Unchanged(p)
+	if !ok {
+		t.Fatal("expected file to be hashable")
+	}
+	if !unchanged {
+		t.Error("expected a content-identical mtime touch to be reported as unchanged after seed")
+	}
+
+	if err := os.WriteFile(p, []byte("different content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, unchanged, ok = c.Unchanged(p)
+	if !ok {
+		t.Fatal("expected file to be hashable")
+	}
+	if unchanged {
+		t.Error("expected a real content change to be reported as changed")
+	}
+}
@@ -0,0 +1,16 @@
+package modd
+
+import "errors"
+
+var (
+	// ErrEventOverflow is sent on WatchConfig.Errors when a backend's event
+	// stream filled up faster than it could be drained and events were
+	// dropped. A full resync is triggered automatically; the error is
+	// informational.
+	ErrEventOverflow = errors.New("modd: event overflow, resynchronizing")
+
+	// ErrWatchLost is sent on WatchConfig.Errors when a watched root can no
+	// longer be found during a resync, meaning the backend's watch on it is
+	// gone for good (the root itself was removed or unmounted).
+	ErrWatchLost = errors.New("modd: watch lost")
+)
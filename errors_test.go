@@ -0,0 +1,133 @@
+package modd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rjeczalik/notify"
+)
+
+// fakeBackend is a Backend whose Events/Overflowed channels a test drives
+// directly, so WatchConfig.Watch's overflow/resync path can be exercised
+// without relying on real OS filesystem notifications.
+type fakeBackend struct {
+	events   chan notify.EventInfo
+	overflow chan struct{}
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{
+		events:   make(chan notify.EventInfo, 16),
+		overflow: make(chan struct{}),
+	}
+}
+
+func (b *fakeBackend) Register(p string, recursive bool) error { return nil }
+func (b *fakeBackend) Events() chan notify.EventInfo           { return b.events }
+func (b *fakeBackend) Overflowed() <-chan struct{}             { return b.overflow }
+func (b *fakeBackend) Close() error                            { return nil }
+
+func TestWatchConfigOverflowTriggersErrorAndResync(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "existing.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	backend := newFakeBackend()
+	errs := make(chan error, 4)
+	ch := make(chan Mod, 4)
+
+	cfg := WatchConfig{
+		Specs:     []WatchSpec{{Path: root, Recursive: true}},
+		BatchTime: 5 * time.Millisecond,
+		Backend:   backend,
+		Errors:    errs,
+	}
+	if err := cfg.Watch(ch); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a change that happened while events were being dropped -
+	// the resync triggered by Overflowed() should pick this up even though
+	// no Create event was ever sent on backend.events.
+	newFile := filepath.Join(root, "new.txt")
+	if err := os.WriteFile(newFile, []byte("y"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	backend.overflow <- struct{}{}
+
+	select {
+	case err := <-errs:
+		if err != ErrEventOverflow {
+			t.Fatalf("expected ErrEventOverflow, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for ErrEventOverflow")
+	}
+
+	select {
+	case mod := <-ch:
+		found := false
+		for _, c := range mod.Changes {
+			if c.Path == newFile && c.Kind == Add {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("expected resync to report %s as Added, got %+v", newFile, mod.Changes)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for resync Mod")
+	}
+}
+
+func TestWatchConfigOverflowReportsWatchLostWhenSpecPathGone(t *testing.T) {
+	root := t.TempDir()
+	watched := filepath.Join(root, "watched")
+	if err := os.Mkdir(watched, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	backend := newFakeBackend()
+	errs := make(chan error, 4)
+	ch := make(chan Mod, 4)
+
+	cfg := WatchConfig{
+		Specs:     []WatchSpec{{Path: watched, Recursive: true}},
+		BatchTime: 5 * time.Millisecond,
+		Backend:   backend,
+		Errors:    errs,
+	}
+	if err := cfg.Watch(ch); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.RemoveAll(watched); err != nil {
+		t.Fatal(err)
+	}
+
+	backend.overflow <- struct{}{}
+
+	var saw struct{ overflow, lost bool }
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			switch err {
+			case ErrEventOverflow:
+				saw.overflow = true
+			case ErrWatchLost:
+				saw.lost = true
+			default:
+				t.Fatalf("unexpected error %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for errors")
+		}
+	}
+	if !saw.overflow || !saw.lost {
+		t.Fatalf("expected both ErrEventOverflow and ErrWatchLost, got overflow=%v lost=%v", saw.overflow, saw.lost)
+	}
+}
@@ -0,0 +1,165 @@
+package modd
+
+import "path/filepath"
+
+// matchAny returns true if p, or its base name, matches any of the given
+// shell glob patterns.
+func matchAny(patterns []string, p string) (bool, error) {
+	for _, pattern := range patterns {
+		matched, err := filepath.Match(pattern, p)
+		if err != nil {
+			return false, err
+		}
+		if matched {
+			return true, nil
+		}
+		matched, err = filepath.Match(pattern, filepath.Base(p))
+		if err != nil {
+			return false, err
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// filterFiles removes any path in files that matches one of the shell glob
+// patterns in excludes.
+func filterFiles(files []string, excludes []string) ([]string, error) {
+	var ret []string
+	for _, f := range files {
+		excluded, err := matchAny(excludes, f)
+		if err != nil {
+			return nil, err
+		}
+		if !excluded {
+			ret = append(ret, f)
+		}
+	}
+	return ret, nil
+}
+
+// specFor returns the WatchSpec whose Path most specifically contains p, if
+// any. Where specs overlap, the spec with the longest Path wins. p and every
+// s.Path are compared after filepath.Clean, since normPath produces cleaned
+// paths (filepath.Join strips a leading "./") while WatchSpec.Path is
+// whatever the caller wrote - a spec given as "./cmd" must still match a
+// normalized path of "cmd/main.go".
+func specFor(specs []WatchSpec, p string) (WatchSpec, bool) {
+	p = filepath.Clean(p)
+	var best WatchSpec
+	var bestPath string
+	found := false
+	for _, s := range specs {
+		sp := filepath.Clean(s.Path)
+		if sp == p || isUnder(sp, p) {
+			if !found || len(sp) > len(bestPath) {
+				best = s
+				bestPath = sp
+				found = true
+			}
+		}
+	}
+	return best, found
+}
+
+// filterSpec applies a single WatchSpec's Include/Exclude patterns to a
+// list of paths.
+func filterSpec(spec WatchSpec, files []string) ([]string, error) {
+	var ret []string
+	for _, f := range files {
+		if len(spec.Include) > 0 {
+			matched, err := matchAny(spec.Include, f)
+			if err != nil {
+				return nil, err
+			}
+			if !matched {
+				continue
+			}
+		}
+		if len(spec.Exclude) > 0 {
+			matched, err := matchAny(spec.Exclude, f)
+			if err != nil {
+				return nil, err
+			}
+			if matched {
+				continue
+			}
+		}
+		if spec.ExcludeMatcher != nil && spec.ExcludeMatcher.Match(f) {
+			continue
+		}
+		ret = append(ret, f)
+	}
+	return ret, nil
+}
+
+// filterSpecs groups files by the WatchSpec that produced them, and applies
+// each spec's own Include/Exclude patterns. Files that don't match any spec
+// are passed through unfiltered.
+func filterSpecs(specs []WatchSpec, files []string) ([]string, error) {
+	bySpec := make(map[string][]string)
+	var unmatched []string
+	for _, f := range files {
+		spec, ok := specFor(specs, f)
+		if !ok {
+			unmatched = append(unmatched, f)
+			continue
+		}
+		bySpec[spec.Path] = append(bySpec[spec.Path], f)
+	}
+	ret := unmatched
+	for _, spec := range specs {
+		group, ok := bySpec[spec.Path]
+		if !ok {
+			continue
+		}
+		filtered, err := filterSpec(spec, group)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, filtered...)
+	}
+	return ret, nil
+}
+
+// filterBySpecs applies filterSpecs to every field of mod.
+func (mod *Mod) filterBySpecs(specs []WatchSpec) (*Mod, error) {
+	changed, err := filterSpecs(specs, mod.Changed)
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := filterSpecs(specs, mod.Deleted)
+	if err != nil {
+		return nil, err
+	}
+	added, err := filterSpecs(specs, mod.Added)
+	if err != nil {
+		return nil, err
+	}
+	keptPaths, err := filterSpecs(specs, mod.pathsOf(mod.Changes))
+	if err != nil {
+		return nil, err
+	}
+	kept := make(map[string]bool, len(keptPaths))
+	for _, p := range keptPaths {
+		kept[p] = true
+	}
+	changes := make([]Change, 0, len(mod.Changes))
+	for _, c := range mod.Changes {
+		if kept[c.Path] {
+			changes = append(changes, c)
+		}
+	}
+	return &Mod{Changed: changed, Deleted: deleted, Added: added, Changes: changes}, nil
+}
+
+// pathsOf returns the Path of every Change in changes.
+func (mod *Mod) pathsOf(changes []Change) []string {
+	ret := make([]string, len(changes))
+	for i, c := range changes {
+		ret[i] = c.Path
+	}
+	return ret
+}
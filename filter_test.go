@@ -0,0 +1,146 @@
+package modd
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSpecForLongestPrefixWins(t *testing.T) {
+	specs := []WatchSpec{
+		{Path: ".", Recursive: true},
+		{Path: "./cmd", Recursive: false},
+	}
+
+	cases := []struct {
+		name     string
+		p        string
+		wantPath string
+		wantOK   bool
+	}{
+		{"file under the more specific spec", "cmd/main.go", "./cmd", true},
+		{"file only under the root spec", "pkg/util.go", ".", true},
+		{"the more specific spec's own path", "cmd", "./cmd", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s, ok := specFor(specs, c.p)
+			if ok != c.wantOK {
+				t.Fatalf("specFor(%q) ok = %v, want %v", c.p, ok, c.wantOK)
+			}
+			if ok && s.Path != c.wantPath {
+				t.Errorf("specFor(%q) = %q, want %q", c.p, s.Path, c.wantPath)
+			}
+		})
+	}
+}
+
+func TestFilterSpecIncludeExclude(t *testing.T) {
+	cases := []struct {
+		name  string
+		spec  WatchSpec
+		files []string
+		want  []string
+	}{
+		{
+			name:  "exclude without include drops only matches",
+			spec:  WatchSpec{Exclude: []string{"*.tmp"}},
+			files: []string{"a.go", "b.tmp", "c.go"},
+			want:  []string{"a.go", "c.go"},
+		},
+		{
+			name:  "include without exclude keeps only matches",
+			spec:  WatchSpec{Include: []string{"*.go"}},
+			files: []string{"a.go", "b.tmp", "c.go"},
+			want:  []string{"a.go", "c.go"},
+		},
+		{
+			name:  "include and exclude combine",
+			spec:  WatchSpec{Include: []string{"*.go"}, Exclude: []string{"*_test.go"}},
+			files: []string{"a.go", "a_test.go", "b.tmp"},
+			want:  []string{"a.go"},
+		},
+		{
+			name:  "neither include nor exclude passes everything through",
+			spec:  WatchSpec{},
+			files: []string{"a.go", "b.tmp"},
+			want:  []string{"a.go", "b.tmp"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := filterSpec(c.spec, c.files)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("filterSpec() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestFilterSpecsRoutesByMostSpecificSpec(t *testing.T) {
+	specs := []WatchSpec{
+		{Path: ".", Recursive: true, Exclude: []string{"*.log"}},
+		{Path: "./cmd", Recursive: false, Include: []string{"*.go"}},
+	}
+	files := []string{
+		"cmd/main.go",   // matches ./cmd's Include
+		"cmd/README",    // fails ./cmd's Include
+		"pkg/util.go",   // only under ".", no Exclude match
+		"pkg/debug.log", // only under ".", matches Exclude
+		"other.txt",     // only under ".", no Exclude match
+	}
+
+	got, err := filterSpecs(specs, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	want := []string{"cmd/main.go", "other.txt", "pkg/util.go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("filterSpecs() = %v, want %v", got, want)
+	}
+}
+
+func TestFilterBySpecsFiltersEveryModField(t *testing.T) {
+	specs := []WatchSpec{
+		{Path: ".", Recursive: true, Exclude: []string{"*.log"}},
+	}
+	mod := &Mod{
+		Added:   []string{"a.go", "a.log"},
+		Changed: []string{"b.go", "b.log"},
+		Deleted: []string{"c.go", "c.log"},
+		Changes: []Change{
+			{Path: "a.go", Kind: Add},
+			{Path: "a.log", Kind: Add},
+			{Path: "b.go", Kind: Modify},
+			{Path: "b.log", Kind: Modify},
+			{Path: "c.go", Kind: Delete},
+			{Path: "c.log", Kind: Delete},
+		},
+	}
+
+	filtered, err := mod.filterBySpecs(specs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(filtered.Added, []string{"a.go"}) {
+		t.Errorf("Added = %v", filtered.Added)
+	}
+	if !reflect.DeepEqual(filtered.Changed, []string{"b.go"}) {
+		t.Errorf("Changed = %v", filtered.Changed)
+	}
+	if !reflect.DeepEqual(filtered.Deleted, []string{"c.go"}) {
+		t.Errorf("Deleted = %v", filtered.Deleted)
+	}
+	if len(filtered.Changes) != 3 {
+		t.Fatalf("expected 3 surviving Changes, got %d: %+v", len(filtered.Changes), filtered.Changes)
+	}
+	for _, c := range filtered.Changes {
+		if c.Path == "a.log" || c.Path == "b.log" || c.Path == "c.log" {
+			t.Errorf("expected %s to be filtered out of Changes", c.Path)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package modd
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Matcher decides whether a path should be excluded. WatchSpec.Exclude
+// patterns are plain shell globs; a Matcher lets a WatchSpec use something
+// more expressive - NewGitignoreMatcher in particular - via its
+// ExcludeMatcher field.
+type Matcher interface {
+	Match(path string) bool
+}
+
+// gitignoreRule is a single pattern parsed from a .gitignore file.
+type gitignoreRule struct {
+	base     string // absolute, slash-separated directory the rule was found in
+	pattern  string // the glob pattern, with any leading/trailing "/" stripped
+	anchored bool   // pattern is anchored to base, rather than matching at any depth under it
+	dirOnly  bool   // pattern had a trailing "/" - only matches a directory, never a plain file
+	negate   bool   // pattern was prefixed with "!"
+}
+
+// gitignoreMatcher is a Matcher built from the .gitignore files found while
+// walking a set of roots. It supports the common subset of gitignore
+// syntax: anchored patterns (a pattern containing a non-trailing "/"),
+// "**", negation with a leading "!", and a directory-only trailing "/". The
+// directory-only check is best-effort: Match only ever sees a path string,
+// so a dirOnly rule stats the candidate to tell a directory from a file,
+// and - since a path that no longer exists (e.g. a Delete change) can't be
+// stat'd either way - treats "can't tell" as a match rather than risk
+// silently failing to exclude a deleted build/vendor directory.
+type gitignoreMatcher struct {
+	roots []string // absolute, so relative paths passed to Match can be resolved against them
+	rules []gitignoreRule
+}
+
+// NewGitignoreMatcher builds a Matcher from every .gitignore file found by
+// walking roots. Rules are applied in the same precedence order git uses:
+// later rules - including ones from a .gitignore deeper in the tree,
+// which filepath.Walk always visits after its ancestors - override earlier
+// ones, and a "!"-prefixed pattern re-includes a path an earlier pattern
+// excluded.
+//
+// Match accepts both absolute paths and paths relative to one of roots.
+// WatchSpec passes ExcludeMatcher the spec's Path joined with the path
+// beneath it, so a matcher rooted at that Path resolves it to the right
+// file when Path is absolute or "."; with any other relative Path, Match
+// resolves the joined path against the root as well, so the Path prefix
+// appears twice.
+func NewGitignoreMatcher(roots []string) Matcher {
+	m := &gitignoreMatcher{}
+	for _, root := range roots {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			abs = root
+		}
+		m.roots = append(m.roots, filepath.ToSlash(abs))
+		filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+			if err != nil || !info.IsDir() {
+				return nil
+			}
+			m.loadGitignore(p)
+			return nil
+		})
+	}
+	return m
+}
+
+func (m *gitignoreMatcher) loadGitignore(dir string) {
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	base, err := filepath.Abs(dir)
+	if err != nil {
+		base = dir
+	}
+	base = filepath.ToSlash(base)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		rule, ok := parseGitignoreLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		rule.base = base
+		m.rules = append(m.rules, rule)
+	}
+}
+
+// parseGitignoreLine compiles a single line of a .gitignore file into a
+// rule, or reports false for blank lines and comments.
+func parseGitignoreLine(line string) (gitignoreRule, bool) {
+	line = strings.TrimRight(line, " \t")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return gitignoreRule{}, false
+	}
+	negate := false
+	if strings.HasPrefix(line, "!") {
+		negate = true
+		line = line[1:]
+	}
+	line = strings.TrimPrefix(line, "\\")
+	dirOnly := strings.HasSuffix(line, "/")
+	if dirOnly {
+		line = strings.TrimSuffix(line, "/")
+	}
+	anchored := strings.HasPrefix(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return gitignoreRule{}, false
+	}
+	if strings.Contains(line, "/") {
+		// an embedded (non-trailing) "/" anchors the pattern to base, per
+		// gitignore(5)
+		anchored = true
+	}
+	return gitignoreRule{pattern: line, anchored: anchored, dirOnly: dirOnly, negate: negate}, true
+}
+
+// Match accepts an absolute path, or a path relative to one of the roots
+// the matcher was built with.
+func (m *gitignoreMatcher) Match(path string) bool {
+	if filepath.IsAbs(path) {
+		return m.matchAbs(filepath.ToSlash(path))
+	}
+	for _, root := range m.roots {
+		if m.matchAbs(filepath.ToSlash(filepath.Join(root, path))) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *gitignoreMatcher) matchAbs(abs string) bool {
+	matched := false
+	for _, r := range m.rules {
+		if !isUnder(r.base, abs) {
+			continue
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(abs, r.base), "/")
+		if rel == "" {
+			continue
+		}
+		segs := strings.Split(rel, "/")
+		if r.anchored {
+			if depth, ok := matchesAnyPrefix(r.pattern, segs); ok && r.matchesDirConstraint(segs[:depth]) {
+				matched = !r.negate
+			}
+			continue
+		}
+		for i := range segs {
+			if depth, ok := matchesAnyPrefix(r.pattern, segs[i:]); ok && r.matchesDirConstraint(segs[:i+depth]) {
+				matched = !r.negate
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// matchesDirConstraint reports whether the directory a pattern matched
+// against - segs, the path segments relative to the rule's base - actually
+// satisfies the rule's directory-only requirement.
+func (r gitignoreRule) matchesDirConstraint(segs []string) bool {
+	if !r.dirOnly {
+		return true
+	}
+	fi, err := os.Stat(filepath.Join(append([]string{r.base}, segs...)...))
+	if err != nil {
+		return true
+	}
+	return fi.IsDir()
+}
+
+// matchesAnyPrefix reports whether pattern matches any leading prefix of
+// segs - so that a pattern matching a directory also matches everything
+// under it, the way git prunes an ignored directory's whole subtree. depth
+// is the length of the matching prefix.
+func matchesAnyPrefix(pattern string, segs []string) (depth int, ok bool) {
+	for i := 1; i <= len(segs); i++ {
+		if matchGitignorePattern(pattern, strings.Join(segs[:i], "/")) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// matchGitignorePattern reports whether rel (slash-separated) matches
+// pattern, which may use "*", "?" and "**" with the usual gitignore
+// meaning: "*" and "?" don't cross a "/", "**" matches any number of path
+// segments including none.
+func matchGitignorePattern(pattern, rel string) bool {
+	return doubleStarMatch(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+func doubleStarMatch(pat, name []string) bool {
+	if len(pat) == 0 {
+		return len(name) == 0
+	}
+	if pat[0] == "**" {
+		if doubleStarMatch(pat[1:], name) {
+			return true
+		}
+		if len(name) == 0 {
+			return false
+		}
+		return doubleStarMatch(pat, name[1:])
+	}
+	if len(name) == 0 {
+		return false
+	}
+	ok, err := filepath.Match(pat[0], name[0])
+	if err != nil || !ok {
+		return false
+	}
+	return doubleStarMatch(pat[1:], name[1:])
+}
@@ -0,0 +1,141 @@
+package modd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitignoreMatcher(t *testing.T) {
+	root := t.TempDir()
+
+	mustWrite := func(p, content string) {
+		full := filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mustWrite(".gitignore", "*.log\n/build/\nsub/deep/**/skip\n!keep.log\n")
+	mustWrite("sub/.gitignore", "local.tmp\n")
+
+	m := NewGitignoreMatcher([]string{root})
+
+	cases := []struct {
+		name string
+		rel  string
+		want bool
+	}{
+		{"unanchored glob matches anywhere", "a.log", true},
+		{"negation re-includes a matched path", "keep.log", false},
+		{"anchored directory pattern prunes its subtree", "build/out.txt", true},
+		{"anchored pattern doesn't match a same-named dir elsewhere", "other/build/out.txt", false},
+		{"nested .gitignore is scoped to its own directory", "sub/local.tmp", true},
+		{"a nested .gitignore's rule doesn't leak to sibling directories", "top.tmp", false},
+		{"** matches across any number of segments", "sub/deep/middle/skip", true},
+		{"** matches zero segments", "sub/deep/skip", true},
+		{"unrelated file is kept", "keepme.txt", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := m.Match(filepath.Join(root, c.rel)); got != c.want {
+				t.Errorf("Match(absolute %q) = %v, want %v", c.rel, got, c.want)
+			}
+			// A path relative to the matcher's root resolves to the same
+			// file as its absolute form.
+			if got := m.Match(c.rel); got != c.want {
+				t.Errorf("Match(relative %q) = %v, want %v", c.rel, got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseGitignoreLine(t *testing.T) {
+	cases := []struct {
+		line     string
+		ok       bool
+		pattern  string
+		anchored bool
+		dirOnly  bool
+		negate   bool
+	}{
+		{"", false, "", false, false, false},
+		{"# a comment", false, "", false, false, false},
+		{"*.log", true, "*.log", false, false, false},
+		{"/build", true, "build", true, false, false},
+		{"build/", true, "build", false, true, false},
+		{"a/b", true, "a/b", true, false, false},
+		{"!keep.log", true, "keep.log", false, false, true},
+	}
+	for _, c := range cases {
+		t.Run(c.line, func(t *testing.T) {
+			rule, ok := parseGitignoreLine(c.line)
+			if ok != c.ok {
+				t.Fatalf("parseGitignoreLine(%q) ok = %v, want %v", c.line, ok, c.ok)
+			}
+			if !ok {
+				return
+			}
+			if rule.pattern != c.pattern || rule.anchored != c.anchored || rule.dirOnly != c.dirOnly || rule.negate != c.negate {
+				t.Errorf("parseGitignoreLine(%q) = %+v, want pattern=%q anchored=%v dirOnly=%v negate=%v",
+					c.line, rule, c.pattern, c.anchored, c.dirOnly, c.negate)
+			}
+		})
+	}
+}
+
+func TestGitignoreMatcherDirectoryOnlyPattern(t *testing.T) {
+	root := t.TempDir()
+
+	mustWrite := func(p, content string) {
+		full := filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// "build/" should exclude a directory named build and everything in
+	// it, but not a plain file that happens to be named build.
+	mustWrite(".gitignore", "build/\n")
+	mustWrite("build/out.txt", "x")
+	if err := os.Mkdir(filepath.Join(root, "other"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	mustWrite("other/build", "x") // a file, not a directory
+
+	m := NewGitignoreMatcher([]string{root})
+
+	if !m.Match(filepath.Join(root, "build")) {
+		t.Error("expected the build/ directory itself to match")
+	}
+	if !m.Match(filepath.Join(root, "build/out.txt")) {
+		t.Error("expected a file inside build/ to match")
+	}
+	if m.Match(filepath.Join(root, "other/build")) {
+		t.Error("a directory-only pattern must not match a plain file of the same name")
+	}
+}
+
+func TestDoubleStarMatch(t *testing.T) {
+	cases := []struct {
+		pattern, rel string
+		want         bool
+	}{
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a/**/b", "a/x/c", false},
+		{"*.go", "main.go", true},
+		{"*.go", "sub/main.go", false},
+	}
+	for _, c := range cases {
+		if got := matchGitignorePattern(c.pattern, c.rel); got != c.want {
+			t.Errorf("matchGitignorePattern(%q, %q) = %v, want %v", c.pattern, c.rel, got, c.want)
+		}
+	}
+}
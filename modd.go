@@ -2,7 +2,6 @@ package modd
 
 import (
 	"os"
-	"path"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -24,10 +23,16 @@ func defaultLogger() termlog.Logger {
 	return l
 }
 
-// isUnder takes two absolute paths, and returns true if child is under parent.
+// isUnder takes two absolute paths, and returns true if child is under
+// parent. parent == "." - the cleaned form of the watch root specs are
+// normalized relative to - matches every relative child, since there's no
+// "./" prefix left on child for a literal prefix check to find.
 func isUnder(parent string, child string) bool {
 	parent = filepath.ToSlash(parent)
 	child = filepath.ToSlash(child)
+	if parent == "." {
+		return true
+	}
 	off := strings.Index(child, parent)
 	if off == 0 && (len(child) == len(parent) || child[len(parent)] == '/') {
 		return true
@@ -81,8 +86,29 @@ func (sc statExistenceChecker) Check(p string) bool {
 	return false
 }
 
-// Mod encapsulates a set of changes
+// WatchSpec describes a single path to watch, and how to watch it. Path may
+// be a file or a directory. If Recursive is true and Path is a directory,
+// all of its subdirectories are watched as well - otherwise only Path
+// itself and its direct entries are watched. Include and Exclude are glob patterns
+// matched against paths as they're produced for this spec: if Include is
+// non-empty, a path must match at least one Include pattern to be kept, and
+// any path matching an Exclude pattern is then dropped. ExcludeMatcher, if
+// set, is consulted in addition to Exclude - NewGitignoreMatcher is the
+// usual way to build one, so a spec can be excluded using .gitignore files
+// discovered under Path rather than a flat list of globs.
+type WatchSpec struct {
+	Path           string
+	Recursive      bool
+	Include        []string
+	Exclude        []string
+	ExcludeMatcher Matcher
+}
+
+// Mod encapsulates a set of changes. Changes is the authoritative,
+// structured list of what happened; Added, Changed and Deleted are derived
+// views over it, kept for backward compatibility.
 type Mod struct {
+	Changes []Change
 	Changed []string
 	Deleted []string
 	Added   []string
@@ -125,7 +151,17 @@ func (mod *Mod) Filter(excludes []string) (*Mod, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Mod{Changed: changed, Deleted: deleted, Added: added}, nil
+	changes := make([]Change, 0, len(mod.Changes))
+	for _, c := range mod.Changes {
+		excluded, err := matchAny(excludes, c.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !excluded {
+			changes = append(changes, c)
+		}
+	}
+	return &Mod{Changed: changed, Deleted: deleted, Added: added, Changes: changes}, nil
 }
 
 func (mod *Mod) normPaths(bases []string) (*Mod, error) {
@@ -141,7 +177,23 @@ func (mod *Mod) normPaths(bases []string) (*Mod, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Mod{Changed: changed, Deleted: deleted, Added: added}, nil
+	changes := make([]Change, len(mod.Changes))
+	for i, c := range mod.Changes {
+		np, err := normPath(bases, c.Path)
+		if err != nil {
+			return nil, err
+		}
+		c.Path = np
+		if c.OldPath != "" {
+			nop, err := normPath(bases, c.OldPath)
+			if err != nil {
+				return nil, err
+			}
+			c.OldPath = nop
+		}
+		changes[i] = c
+	}
+	return &Mod{Changed: changed, Deleted: deleted, Added: added, Changes: changes}, nil
 }
 
 func _keys(m map[string]bool) []string {
@@ -174,7 +226,7 @@ func _keys(m map[string]bool) []string {
 //
 // In the face of all this, all we can do is layer on a set of heuristics to
 // try to get intuitive results.
-func batch(batchTime time.Duration, exists existenceChecker, ch chan notify.EventInfo) *Mod {
+func batch(batchTime time.Duration, exists existenceChecker, ch chan notify.EventInfo, renames *renameCache, content *contentCache) *Mod {
 	added := make(map[string]bool)
 	removed := make(map[string]bool)
 	changed := make(map[string]bool)
@@ -183,6 +235,11 @@ func batch(batchTime time.Duration, exists existenceChecker, ch chan notify.Even
 		select {
 		case evt := <-ch:
 			Logger.SayAs("debug", "%s", evt)
+			// Keep the rename cache fresh - by the time we come to pair up
+			// a Remove or Rename event against an Add, the source path is
+			// almost always already gone, so this is our only chance to
+			// record what it looked like.
+			renames.observe(evt.Path())
 			switch evt.Event() {
 			case notify.Create:
 				added[evt.Path()] = true
@@ -194,7 +251,6 @@ func batch(batchTime time.Duration, exists existenceChecker, ch chan notify.Even
 				renamed[evt.Path()] = true
 			}
 		case <-time.After(batchTime):
-			ret := &Mod{}
 			for k := range renamed {
 				// If a file is moved from A to B, we'll get separate rename
 				// events for both A and B. The only way to know if it was the
@@ -229,50 +285,178 @@ func batch(batchTime time.Duration, exists existenceChecker, ch chan notify.Even
 					delete(changed, k)
 				}
 			}
-			ret.Added = _keys(added)
-			ret.Changed = _keys(changed)
-			ret.Deleted = _keys(removed)
-			return ret
+
+			// Try to pair up adds and removes that are probably the two
+			// halves of the same rename, using stat data the cache recorded
+			// for each path while it still existed.
+			renameOf := make(map[string]string) // new path -> old path
+			pairedOld := make(map[string]bool)
+			for a := range added {
+				asi, aok := renames.lookup(a)
+				if !aok {
+					continue
+				}
+				for r := range removed {
+					if pairedOld[r] {
+						continue
+					}
+					rsi, rok := renames.lookup(r)
+					if rok && sameFile(asi, rsi) {
+						renameOf[a] = r
+						pairedOld[r] = true
+						break
+					}
+				}
+			}
+
+			changeMap := make(map[string]Change)
+			for a := range added {
+				si, _ := renames.lookup(a)
+				hash, _ := hashIfEnabled(content, a)
+				if old, ok := renameOf[a]; ok {
+					delete(removed, old)
+					renames.rename(old, a)
+					changeMap[a] = Change{Path: a, Kind: Rename, OldPath: old, IsDir: si.isDir(), Size: si.size, ModTime: si.mod, Hash: hash}
+					continue
+				}
+				changeMap[a] = Change{Path: a, Kind: Add, IsDir: si.isDir(), Size: si.size, ModTime: si.mod, Hash: hash}
+			}
+			for k := range changed {
+				si, _ := renames.lookup(k)
+				hash, unchanged := hashIfEnabled(content, k)
+				if unchanged {
+					continue
+				}
+				changeMap[k] = Change{Path: k, Kind: Modify, IsDir: si.isDir(), Size: si.size, ModTime: si.mod, Hash: hash}
+			}
+			for k := range removed {
+				changeMap[k] = Change{Path: k, Kind: Delete}
+				renames.forget(k)
+			}
+
+			keys := make(map[string]bool, len(changeMap))
+			for k := range changeMap {
+				keys[k] = true
+			}
+			changes := make([]Change, 0, len(changeMap))
+			for _, k := range _keys(keys) {
+				changes = append(changes, changeMap[k])
+			}
+			return changesToMod(changes)
 		}
 	}
 }
 
-// Watch watches a path p, batching events with duration batchTime. A list of
-// strings are written to chan, representing all files changed, added or
-// removed. We apply heuristics to cope with things like transient files and
-// unreliable event notifications.
-func Watch(paths []string, excludes []string, batchTime time.Duration, ch chan Mod) error {
-	evtch := make(chan notify.EventInfo, 1024)
-	for _, p := range paths {
-		stat, err := os.Stat(p)
-		if err != nil {
+// WatchConfig configures a call to Watch - it extends the basic
+// (specs, batchTime) pair with the Backend used to source events.
+type WatchConfig struct {
+	Specs     []WatchSpec
+	BatchTime time.Duration
+	// Backend is the event source to use. If nil, a Backend backed by
+	// rjeczalik/notify - the platform's native watch mechanism - is used.
+	Backend Backend
+	// Errors, if non-nil, receives ErrEventOverflow and ErrWatchLost
+	// whenever the backend's event stream may have missed changes. A
+	// resync Mod is emitted on the regular channel regardless of whether
+	// Errors is set.
+	Errors chan error
+	// ContentHash enables content-hash debouncing: a Modify change is
+	// dropped if the file's content hash is unchanged since the last time
+	// it was seen. See contentCache for why this is worth doing.
+	ContentHash bool
+	// ContentHashSizeCap caps how large a file ContentHash will read to
+	// hash, in bytes. Larger files are always reported as changed. Zero
+	// means defaultContentHashSizeCap.
+	ContentHashSizeCap int64
+}
+
+// Watch starts watching according to cfg, batching events and delivering
+// Mod values on ch. We apply heuristics to cope with things like transient
+// files and unreliable event notifications.
+func (cfg WatchConfig) Watch(ch chan Mod) error {
+	backend := cfg.Backend
+	if backend == nil {
+		backend = newNotifyBackend()
+	}
+	bases := make([]string, len(cfg.Specs))
+	for i, spec := range cfg.Specs {
+		bases[i] = spec.Path
+		if err := backend.Register(spec.Path, spec.Recursive); err != nil {
 			return err
 		}
-		if stat.IsDir() {
-			p = path.Join(p, "...")
+	}
+	renames := newRenameCache()
+	for _, spec := range cfg.Specs {
+		renames.seed(spec.Path, spec.Recursive)
+	}
+	var content *contentCache
+	if cfg.ContentHash {
+		sizeCap := cfg.ContentHashSizeCap
+		if sizeCap == 0 {
+			sizeCap = defaultContentHashSizeCap
 		}
-		err = notify.Watch(p, evtch, notify.All)
+		content = newContentCache(sizeCap)
+		for _, spec := range cfg.Specs {
+			content.seed(spec.Path)
+		}
+	}
+	emit := func(changes []Change) {
+		ret, err := changesToMod(changes).normPaths(bases)
 		if err != nil {
-			return err
+			Logger.Shout("Error normalising paths: %s", err)
+			return
+		}
+		ret, err = ret.filterBySpecs(cfg.Specs)
+		if err != nil {
+			Logger.Shout("Error filtering paths: %s", err)
+			return
+		}
+		if !ret.Empty() {
+			ch <- *ret
 		}
 	}
 	go func() {
 		for {
-			ret := batch(batchTime, statExistenceChecker{}, evtch)
+			ret := batch(cfg.BatchTime, statExistenceChecker{}, backend.Events(), renames, content)
 			if ret != nil {
-				ret, err := ret.normPaths(paths)
-				if err != nil {
-					Logger.Shout("Error normalising paths: %s", err)
-				}
-				ret, err = ret.Filter(excludes)
-				if err != nil {
-					Logger.Shout("Error filtering paths: %s", err)
-				}
-				if !ret.Empty() {
-					ch <- *ret
+				emit(ret.Changes)
+			}
+		}
+	}()
+	go func() {
+		for range backend.Overflowed() {
+			if cfg.Errors != nil {
+				cfg.Errors <- ErrEventOverflow
+			}
+			for _, spec := range cfg.Specs {
+				if _, err := os.Stat(spec.Path); err != nil && cfg.Errors != nil {
+					cfg.Errors <- ErrWatchLost
 				}
 			}
+			emit(renames.resync(cfg.Specs))
 		}
 	}()
 	return nil
 }
+
+// WatchSpecs watches a set of WatchSpecs, batching events with duration
+// batchTime. A list of strings are written to chan, representing all files
+// changed, added or removed. This is a compatibility wrapper around
+// WatchConfig.Watch using the default notify-backed Backend.
+func WatchSpecs(specs []WatchSpec, batchTime time.Duration, ch chan Mod) error {
+	return WatchConfig{Specs: specs, BatchTime: batchTime}.Watch(ch)
+}
+
+// Watch watches a list of paths, batching events with duration batchTime. A
+// list of strings are written to chan, representing all files changed,
+// added or removed. We apply heuristics to cope with things like transient
+// files and unreliable event notifications. This is a compatibility
+// wrapper around WatchSpecs, watching every path recursively and applying
+// excludes uniformly across all of them.
+func Watch(paths []string, excludes []string, batchTime time.Duration, ch chan Mod) error {
+	specs := make([]WatchSpec, len(paths))
+	for i, p := range paths {
+		specs[i] = WatchSpec{Path: p, Recursive: true, Exclude: excludes}
+	}
+	return WatchSpecs(specs, batchTime, ch)
+}
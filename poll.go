@@ -0,0 +1,156 @@
+package modd
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rjeczalik/notify"
+)
+
+// PollBackend is a Backend that discovers changes by periodically walking
+// its registered paths and comparing stat results against the previous
+// walk, rather than relying on OS filesystem change notifications. It's a
+// portable fallback for environments where those notifications are
+// unreliable or unavailable, at the cost of only noticing changes once per
+// Interval and of being unable to tell a Rename from a Delete plus an Add.
+type PollBackend struct {
+	// Interval is how often the registered paths are re-walked.
+	Interval time.Duration
+
+	ch        chan notify.EventInfo
+	closec    chan struct{}
+	overflow  chan struct{}
+	startOnce sync.Once
+
+	mu    sync.Mutex
+	roots []pollRoot
+}
+
+type pollRoot struct {
+	path      string
+	recursive bool
+}
+
+// NewPollBackend creates a PollBackend that re-walks its registered paths
+// every interval.
+func NewPollBackend(interval time.Duration) *PollBackend {
+	return &PollBackend{
+		Interval: interval,
+		ch:       make(chan notify.EventInfo, 1024),
+		closec:   make(chan struct{}),
+		overflow: make(chan struct{}, 1),
+	}
+}
+
+func (b *PollBackend) Register(p string, recursive bool) error {
+	if _, err := os.Stat(p); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	b.roots = append(b.roots, pollRoot{path: p, recursive: recursive})
+	b.mu.Unlock()
+	return nil
+}
+
+// Events starts the polling loop, if it hasn't already been started, and
+// returns the channel events are delivered on. Starting here rather than in
+// Register means the first walk - the baseline prev has nothing to diff
+// against - only happens once a caller is actually ready to consume events,
+// by which point every Register call a WatchConfig.Watch makes up front is
+// guaranteed to have landed; starting eagerly on the first Register raced
+// the initial walk against later Register calls, both corrupting b.roots
+// and sometimes reporting every file under a not-yet-walked root as a
+// spurious Create once it was finally added.
+func (b *PollBackend) Events() chan notify.EventInfo {
+	b.startOnce.Do(func() { go b.run() })
+	return b.ch
+}
+
+func (b *PollBackend) Overflowed() <-chan struct{} {
+	return b.overflow
+}
+
+func (b *PollBackend) Close() error {
+	close(b.closec)
+	return nil
+}
+
+func (b *PollBackend) run() {
+	prev := b.walk()
+	ticker := time.NewTicker(b.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.closec:
+			return
+		case <-ticker.C:
+			cur := b.walk()
+			b.diff(prev, cur)
+			prev = cur
+		}
+	}
+}
+
+// walk stats every registered root, returning a path -> FileInfo map of
+// everything found.
+func (b *PollBackend) walk() map[string]os.FileInfo {
+	b.mu.Lock()
+	roots := make([]pollRoot, len(b.roots))
+	copy(roots, b.roots)
+	b.mu.Unlock()
+
+	found := make(map[string]os.FileInfo)
+	for _, root := range roots {
+		walkSpec(root.path, root.recursive, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			found[p] = info
+			return nil
+		})
+	}
+	return found
+}
+
+// diff compares two walks and emits Create, Write and Remove events for
+// whatever differs between them.
+func (b *PollBackend) diff(prev, cur map[string]os.FileInfo) {
+	for p, info := range cur {
+		old, ok := prev[p]
+		if !ok {
+			b.emit(p, notify.Create)
+			continue
+		}
+		if old.ModTime() != info.ModTime() || old.Size() != info.Size() {
+			b.emit(p, notify.Write)
+		}
+	}
+	for p := range prev {
+		if _, ok := cur[p]; !ok {
+			b.emit(p, notify.Remove)
+		}
+	}
+}
+
+func (b *PollBackend) emit(p string, e notify.Event) {
+	select {
+	case b.ch <- pollEvent{path: p, event: e}:
+	default:
+		select {
+		case b.overflow <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// pollEvent implements notify.EventInfo, so PollBackend can feed the same
+// batch() machinery as notifyBackend.
+type pollEvent struct {
+	path  string
+	event notify.Event
+}
+
+func (e pollEvent) Path() string        { return e.path }
+func (e pollEvent) Event() notify.Event { return e.event }
+func (e pollEvent) Sys() interface{}    { return nil }
@@ -0,0 +1,130 @@
+package modd
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rjeczalik/notify"
+)
+
+func TestPollBackendRegisterTwoRootsNoSpuriousEvents(t *testing.T) {
+	rootA := t.TempDir()
+	rootB := t.TempDir()
+	if err := os.WriteFile(filepath.Join(rootA, "a.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(rootB, "b.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewPollBackend(10 * time.Millisecond)
+	defer b.Close()
+
+	// Mirrors WatchConfig.Watch: every Register call happens before anyone
+	// reads Events(), so the first walk must see both roots already seeded
+	// rather than treating a root registered after that walk as all-new.
+	if err := b.Register(rootA, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Register(rootB, true); err != nil {
+		t.Fatal(err)
+	}
+
+	events := b.Events()
+	select {
+	case evt := <-events:
+		t.Fatalf("expected no events for pre-existing files on the first poll, got %+v", evt)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestPollBackendConcurrentRegisterAndWalk exercises Register being called
+// concurrently with the run loop's own reads of b.roots, under -race - the
+// scenario that used to corrupt the roots slice when run() started on the
+// very first Register rather than on first Events().
+func TestPollBackendConcurrentRegisterAndWalk(t *testing.T) {
+	root := t.TempDir()
+	b := NewPollBackend(time.Millisecond)
+	defer b.Close()
+	b.Events() // starts run()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		dir := filepath.Join(root, string(rune('a'+i)))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func(p string) {
+			defer wg.Done()
+			if err := b.Register(p, true); err != nil {
+				t.Error(err)
+			}
+		}(dir)
+	}
+	wg.Wait()
+}
+
+func TestPollBackendDiffDetectsCreateWriteRemove(t *testing.T) {
+	now := time.Now()
+
+	cases := []struct {
+		name      string
+		prev, cur map[string]os.FileInfo
+		wantPath  string
+		wantEvent notify.Event
+	}{
+		{
+			name:      "create",
+			prev:      map[string]os.FileInfo{},
+			cur:       map[string]os.FileInfo{"new.txt": &fakeFileInfo{name: "new.txt", modTime: now}},
+			wantPath:  "new.txt",
+			wantEvent: notify.Create,
+		},
+		{
+			name:      "write",
+			prev:      map[string]os.FileInfo{"f.txt": &fakeFileInfo{name: "f.txt", size: 1, modTime: now}},
+			cur:       map[string]os.FileInfo{"f.txt": &fakeFileInfo{name: "f.txt", size: 2, modTime: now}},
+			wantPath:  "f.txt",
+			wantEvent: notify.Write,
+		},
+		{
+			name:      "remove",
+			prev:      map[string]os.FileInfo{"gone.txt": &fakeFileInfo{name: "gone.txt", modTime: now}},
+			cur:       map[string]os.FileInfo{},
+			wantPath:  "gone.txt",
+			wantEvent: notify.Remove,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			b := NewPollBackend(time.Hour)
+			defer b.Close()
+			b.diff(c.prev, c.cur)
+			select {
+			case evt := <-b.ch:
+				if evt.Path() != c.wantPath || evt.Event() != c.wantEvent {
+					t.Errorf("got %s/%v, want %s/%v", evt.Path(), evt.Event(), c.wantPath, c.wantEvent)
+				}
+			default:
+				t.Fatalf("expected a %v event", c.wantEvent)
+			}
+		})
+	}
+}
+
+type fakeFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (f *fakeFileInfo) Name() string       { return f.name }
+func (f *fakeFileInfo) Size() int64        { return f.size }
+func (f *fakeFileInfo) Mode() os.FileMode  { return 0644 }
+func (f *fakeFileInfo) ModTime() time.Time { return f.modTime }
+func (f *fakeFileInfo) IsDir() bool        { return false }
+func (f *fakeFileInfo) Sys() interface{}   { return nil }